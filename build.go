@@ -32,6 +32,10 @@ type Build struct {
 	Target            // Where to run the build.
 	Template          // What to actually build.
 	Env      []string // Environment variables in the form `KEY=VALUE`.
+
+	// ChecksumAlgorithm computes the digest stored in the cache directory for
+	// each command. Defaults to SHA256Checksum when left nil.
+	ChecksumAlgorithm ChecksumAlgorithm
 }
 
 // This will render the build's template into a package and run all its tasks.
@@ -40,10 +44,18 @@ func (b *Build) Run() error {
 	if e != nil {
 		return e
 	}
+	return b.runTasks(pkg)
+}
+
+// runTasks executes pkg's tasks against the build's target. pkg must already
+// have been prepared for this target (see prepareTargetAndTasks). It is
+// split out from Run so that other callers, such as RunMany, can share the
+// same per-target task-running step without duplicating it.
+func (b *Build) runTasks(pkg *packageImpl) error {
 	m := message(pubsub.MessageRunlistsProvision, b.hostname(), "")
 	m.Publish("started")
 	for _, task := range pkg.tasks {
-		if e = b.buildTask(task); e != nil {
+		if e := b.buildTask(task); e != nil {
 			m.PublishError(e)
 			return e
 		}
@@ -83,22 +95,35 @@ func (build *Build) prepareBuild() (*packageImpl, error) {
 		return nil, e
 	}
 
-	if e = build.prepareTarget(); e != nil {
+	if e = build.prepareTargetAndTasks(pkg); e != nil {
 		return nil, e
 	}
 
+	return pkg, nil
+}
+
+// prepareTargetAndTasks prepares the target and checks which of pkg's tasks
+// are already cached. Unlike renderTemplate, which only has to run once for
+// a given template, this talks to the target and therefore has to run once
+// per target (see RunMany, which renders a template a single time and then
+// fans this step out across many targets).
+func (build *Build) prepareTargetAndTasks(pkg *packageImpl) error {
+	if e := build.prepareTarget(); e != nil {
+		return e
+	}
+
 	ct, e := build.buildChecksumTree()
 	if e != nil {
-		return nil, fmt.Errorf("error building checksum tree: %s", e.Error())
+		return fmt.Errorf("error building checksum tree: %s", e.Error())
 	}
 
 	for _, task := range pkg.tasks {
 		if e = build.prepareTask(task, ct); e != nil {
-			return nil, e
+			return e
 		}
 	}
 
-	return pkg, nil
+	return nil
 }
 
 func (build *Build) prepareTarget() error {
@@ -165,8 +190,9 @@ func (build *Build) prepareTask(tsk *task, ct checksumTree) (e error) {
 	}
 
 	// find commands that need not be executed
+	alg := build.checksumAlgorithm()
 	for i, cmd := range tsk.commands {
-		checksum, e := commandChecksum(cmd.command)
+		checksum, e := commandChecksum(alg, cmd.command)
 		if e != nil {
 			return e
 		}
@@ -187,6 +213,16 @@ func (build *Build) buildTask(tsk *task) (e error) {
 
 	tsk.started = time.Now()
 
+	if e := build.truncateJournal(tsk.name); e != nil {
+		// This run's commands haven't done anything yet, so there's nothing
+		// for Rollback to lose by warning instead of failing here; leaving
+		// the previous run's (possibly stale) journal in place is still
+		// better than aborting the build over it.
+		warn := message(pubsub.MessageRunlistsProvisionTask, build.hostname(), tsk.name)
+		warn.Message = "failed to truncate rollback journal: " + e.Error()
+		warn.Publish("warning")
+	}
+
 	for _, cmd := range tsk.commands {
 		m := message(pubsub.MessageRunlistsProvisionTask, build.hostname(), tsk.name)
 		m.TaskChecksum = cmd.Checksum()
@@ -195,6 +231,12 @@ func (build *Build) buildTask(tsk *task) (e error) {
 		if cmd.cached { // Task is cached.
 			m.ExecStatus = pubsub.StatusCached
 			m.Publish("finished")
+
+			if e := build.appendJournal(tsk.name, cmd.command); e != nil {
+				warn := message(pubsub.MessageRunlistsProvisionTask, build.hostname(), tsk.name)
+				warn.Message = "failed to journal command for rollback: " + e.Error()
+				warn.Publish("warning")
+			}
 			continue
 		}
 
@@ -216,6 +258,15 @@ func (build *Build) buildTask(tsk *task) (e error) {
 		if e != nil {
 			return e
 		}
+
+		if e := build.appendJournal(tsk.name, cmd.command); e != nil {
+			// The command itself already succeeded; failing to journal it
+			// only degrades Rollback's ability to undo this one command
+			// later, so warn instead of failing the whole build over it.
+			warn := message(pubsub.MessageRunlistsProvisionTask, build.hostname(), tsk.name)
+			warn.Message = "failed to journal command for rollback: " + e.Error()
+			warn.Publish("warning")
+		}
 	}
 
 	return nil
@@ -252,8 +303,8 @@ func (build *Build) buildChecksumTree() (ct checksumTree, e error) {
 
 		pkgname := filepath.Dir(strings.TrimPrefix(line, ukCACHEDIR+"/"))
 		checksum := strings.TrimSuffix(filepath.Base(line), ".done")
-		if len(checksum) != 64 {
-			return nil, fmt.Errorf("invalid checksum %q found for package %q", checksum, pkgname)
+		if _, e := splitChecksum(checksum); e != nil {
+			return nil, fmt.Errorf("invalid checksum found for package %q: %s", pkgname, e.Error())
 		}
 		ct[pkgname] = append(ct[pkgname], checksum)
 	}
@@ -0,0 +1,127 @@
+package urknall
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"strings"
+
+	"github.com/zeebo/blake3"
+)
+
+// ChecksumAlgorithm computes the digest used to detect whether a command's
+// definition has changed since it last ran. A Build defaults to SHA256Checksum
+// when its ChecksumAlgorithm field is left nil. Checksums are always stored
+// with the algorithm name as a prefix, so a bare hex entry written by an
+// earlier version is still parsed (see splitChecksum) but never matches a
+// command's current checksum, and that command is run again.
+type ChecksumAlgorithm interface {
+	// Name identifies the algorithm and is stored as the on-disk filename
+	// prefix, e.g. "sha256-<hex>.done", so caches that mix algorithms on the
+	// same host remain valid.
+	Name() string
+
+	// Sum returns the hex-encoded digest of b.
+	Sum(b []byte) string
+
+	// HexLen is the length of the hex-encoded digest Sum returns.
+	HexLen() int
+}
+
+// SHA256Checksum, SHA512Checksum and BLAKE3Checksum are the ChecksumAlgorithm
+// implementations built into urknall.
+var (
+	SHA256Checksum ChecksumAlgorithm = sha256Algorithm{}
+	SHA512Checksum ChecksumAlgorithm = sha512Algorithm{}
+	BLAKE3Checksum ChecksumAlgorithm = blake3Algorithm{}
+)
+
+// customChecksumPrefix marks a checksum a command computed itself, via its
+// own Checksum() method, bypassing a Build's configured ChecksumAlgorithm
+// entirely. commandChecksum still prefixes these so every ".done" filename
+// has the "<prefix>-..." shape buildChecksumTree expects, but splitChecksum
+// accepts any length for it since it isn't the output of one of the
+// ChecksumAlgorithm implementations above.
+const customChecksumPrefix = "custom"
+
+type sha256Algorithm struct{}
+
+func (sha256Algorithm) Name() string        { return "sha256" }
+func (sha256Algorithm) HexLen() int         { return 64 }
+func (sha256Algorithm) Sum(b []byte) string { s := sha256.Sum256(b); return fmt.Sprintf("%x", s) }
+
+type sha512Algorithm struct{}
+
+func (sha512Algorithm) Name() string        { return "sha512" }
+func (sha512Algorithm) HexLen() int         { return 128 }
+func (sha512Algorithm) Sum(b []byte) string { s := sha512.Sum512(b); return fmt.Sprintf("%x", s) }
+
+type blake3Algorithm struct{}
+
+func (blake3Algorithm) Name() string        { return "blake3" }
+func (blake3Algorithm) HexLen() int         { return 64 }
+func (blake3Algorithm) Sum(b []byte) string { s := blake3.Sum256(b); return fmt.Sprintf("%x", s) }
+
+// checksumAlgorithm returns build's configured ChecksumAlgorithm, defaulting
+// to SHA256Checksum.
+func (build *Build) checksumAlgorithm() ChecksumAlgorithm {
+	if build.ChecksumAlgorithm != nil {
+		return build.ChecksumAlgorithm
+	}
+	return SHA256Checksum
+}
+
+// splitChecksum parses a "<algorithm>-<hex>" filename stem as written by
+// commandChecksum, returning the ChecksumAlgorithm it was computed with, or
+// nil if the checksum carries the customChecksumPrefix instead of one of the
+// built-in algorithm names.
+//
+// For backwards compatibility with cache trees written before algorithm
+// prefixes existed, a bare 64-character hex stem with no "-" is accepted as
+// an implicit SHA256Checksum, the same way the original unprefixed format's
+// "len(checksum) != 64" check treated it.
+func splitChecksum(stem string) (ChecksumAlgorithm, error) {
+	algos := []ChecksumAlgorithm{SHA256Checksum, SHA512Checksum, BLAKE3Checksum}
+
+	if isLegacyChecksum(stem) {
+		return SHA256Checksum, nil
+	}
+
+	parts := strings.SplitN(stem, "-", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("checksum %q is missing its algorithm prefix", stem)
+	}
+
+	if parts[0] == customChecksumPrefix {
+		return nil, nil
+	}
+
+	for _, alg := range algos {
+		if alg.Name() != parts[0] {
+			continue
+		}
+		if len(parts[1]) != alg.HexLen() {
+			return nil, fmt.Errorf("checksum %q has invalid length for algorithm %q", stem, alg.Name())
+		}
+		return alg, nil
+	}
+	return nil, fmt.Errorf("checksum %q uses unknown algorithm %q", stem, parts[0])
+}
+
+// isLegacyChecksum reports whether stem is a bare hex digest in the format
+// written before algorithm prefixes existed: exactly SHA256Checksum.HexLen()
+// hex digits, with no "-" separated prefix at all.
+func isLegacyChecksum(stem string) bool {
+	if len(stem) != SHA256Checksum.HexLen() {
+		return false
+	}
+	for _, r := range stem {
+		switch {
+		case r >= '0' && r <= '9':
+		case r >= 'a' && r <= 'f':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,94 @@
+package urknall
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSplitChecksum(t *testing.T) {
+	Convey("Given checksums computed with the built-in algorithms", t, func() {
+		Convey("A sha256 checksum is recognized", func() {
+			alg, e := splitChecksum(SHA256Checksum.Name() + "-" + SHA256Checksum.Sum([]byte("foo")))
+			So(e, ShouldBeNil)
+			So(alg, ShouldEqual, SHA256Checksum)
+		})
+
+		Convey("A blake3 checksum is recognized", func() {
+			alg, e := splitChecksum(BLAKE3Checksum.Name() + "-" + BLAKE3Checksum.Sum([]byte("foo")))
+			So(e, ShouldBeNil)
+			So(alg, ShouldEqual, BLAKE3Checksum)
+		})
+
+		Convey("A checksum without an algorithm prefix is rejected", func() {
+			_, e := splitChecksum("deadbeef")
+			So(e, ShouldNotBeNil)
+		})
+
+		Convey("A legacy bare 64-character hex checksum, as written before algorithm prefixes existed, is accepted as sha256", func() {
+			alg, e := splitChecksum(SHA256Checksum.Sum([]byte("foo")))
+			So(e, ShouldBeNil)
+			So(alg, ShouldEqual, SHA256Checksum)
+		})
+
+		Convey("A checksum with the wrong length for its algorithm is rejected", func() {
+			_, e := splitChecksum(SHA256Checksum.Name() + "-deadbeef")
+			So(e, ShouldNotBeNil)
+		})
+
+		Convey("A checksum with an unknown algorithm is rejected", func() {
+			_, e := splitChecksum("md5-deadbeef")
+			So(e, ShouldNotBeNil)
+		})
+	})
+
+	Convey("Given a checksum for a command with its own Checksum() method", t, func() {
+		Convey("It is accepted regardless of length, since it isn't one of the built-in algorithms", func() {
+			alg, e := splitChecksum(customChecksumPrefix + "-not-a-hex-digest-at-all")
+			So(e, ShouldBeNil)
+			So(alg, ShouldBeNil)
+		})
+	})
+}
+
+type commandWithInputs struct {
+	shell string
+	data  []byte
+}
+
+func (c commandWithInputs) Shell() string    { return c.shell }
+func (c commandWithInputs) Inputs() [][]byte { return [][]byte{c.data} }
+
+func TestCommandChecksumFoldsInInputs(t *testing.T) {
+	Convey("Given two commands with identical Shell() but different Inputs()", t, func() {
+		a, e := commandChecksum(SHA256Checksum, commandWithInputs{shell: "cat > /tmp/foo", data: []byte("one")})
+		So(e, ShouldBeNil)
+		b, e := commandChecksum(SHA256Checksum, commandWithInputs{shell: "cat > /tmp/foo", data: []byte("two")})
+		So(e, ShouldBeNil)
+
+		Convey("Their checksums differ, so a template re-render that only changes rendered content invalidates the cache", func() {
+			So(a, ShouldNotEqual, b)
+		})
+	})
+}
+
+type selfChecksummingCommand struct{}
+
+func (selfChecksummingCommand) Shell() string    { return "echo hi" }
+func (selfChecksummingCommand) Checksum() string { return "whatever-the-command-wants" }
+
+func TestCommandChecksumPrefixesSelfManagedChecksums(t *testing.T) {
+	Convey("Given a command that computes its own checksum", t, func() {
+		checksum, e := commandChecksum(SHA256Checksum, selfChecksummingCommand{})
+		So(e, ShouldBeNil)
+
+		Convey("The stored checksum carries the custom prefix", func() {
+			So(checksum, ShouldEqual, customChecksumPrefix+"-whatever-the-command-wants")
+		})
+
+		Convey("And splitChecksum accepts it", func() {
+			_, e := splitChecksum(checksum)
+			So(e, ShouldBeNil)
+		})
+	})
+}
@@ -37,6 +37,15 @@ type Validator interface {
 	Validate() error
 }
 
+// Commands whose cache entry depends on more than their Shell() text can
+// implement this interface to fold additional data -- file contents,
+// downloaded URLs, templated variables -- into their checksum. Without it,
+// only the Shell() text is hashed, so a change to data that does not appear
+// in Shell() would not invalidate the cache.
+type Inputs interface {
+	Inputs() [][]byte
+}
+
 type ExecCommand interface {
 	StdoutPipe() (io.Reader, error)
 	StderrPipe() (io.Reader, error)
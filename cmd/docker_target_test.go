@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDockerTargetString(t *testing.T) {
+	Convey("Given a DockerTarget", t, func() {
+		target := NewDockerTarget("ubuntu:22.04")
+
+		Convey("Before a container is started it identifies itself by image", func() {
+			So(target.String(), ShouldEqual, "docker:ubuntu:22.04")
+		})
+
+		Convey("Once a container is running it identifies itself by container id", func() {
+			target.containerID = "abcdef0123456789"
+			So(target.String(), ShouldEqual, "docker:abcdef012345")
+		})
+	})
+}
+
+func TestDockerTargetExecArgs(t *testing.T) {
+	Convey("Given a DockerTarget with a running container", t, func() {
+		target := NewDockerTarget("ubuntu:22.04")
+		target.containerID = "deadbeef"
+
+		Convey("execArgs wraps the raw command in a docker exec invocation", func() {
+			So(target.execArgs("echo hi"), ShouldResemble,
+				[]string{"docker", "exec", "-i", "deadbeef", "sh", "-c", "echo hi"})
+		})
+	})
+}
+
+func TestFirstLine(t *testing.T) {
+	Convey("Given docker CLI output", t, func() {
+		Convey("With a single line and no trailing newline", func() {
+			So(firstLine([]byte("deadbeef")), ShouldEqual, "deadbeef")
+		})
+
+		Convey("With a trailing newline", func() {
+			So(firstLine([]byte("deadbeef\n")), ShouldEqual, "deadbeef")
+		})
+
+		Convey("With multiple lines", func() {
+			So(firstLine([]byte("deadbeef\nsome other output\n")), ShouldEqual, "deadbeef")
+		})
+	})
+}
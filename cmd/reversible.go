@@ -0,0 +1,11 @@
+package cmd
+
+// Reversible can be implemented by commands that know how to undo their own
+// effect, e.g. AddUser's Reverse returning a matching userdel invocation, or
+// Mkdir's returning rmdir. Build.Rollback uses it to compensate for a
+// partially applied task. Commands that don't implement it are simply
+// skipped during a rollback, with a warning.
+type Reversible interface {
+	// Reverse returns the shell command that undoes this command's effect.
+	Reverse() string
+}
@@ -0,0 +1,13 @@
+package urknall
+
+import (
+	"github.com/dynport/urknall/cmd"
+)
+
+// NewDockerTarget returns a Target that provisions against a freshly started
+// Docker container rather than a remote host. This is useful for trying out
+// a template such as ElasticSearch locally against an ephemeral rootfs
+// without needing SSH access to a real machine.
+func NewDockerTarget(image string) *cmd.DockerTarget {
+	return cmd.NewDockerTarget(image)
+}
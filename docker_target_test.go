@@ -0,0 +1,14 @@
+package urknall
+
+import (
+	"testing"
+
+	"github.com/dynport/urknall/cmd"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDockerTargetCacheDirMatchesCacheTreeRoot(t *testing.T) {
+	Convey("A DockerTarget's CacheDir mount point matches where buildChecksumTree looks for the checksum tree", t, func() {
+		So(cmd.CacheDir, ShouldEqual, ukCACHEDIR)
+	})
+}
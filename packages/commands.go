@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// InstallPackages returns a command that installs the given packages via
+// apt-get, and knows how to undo that via apt-get remove.
+func InstallPackages(pkgs ...string) *installPackagesCommand {
+	return &installPackagesCommand{Packages: pkgs}
+}
+
+type installPackagesCommand struct {
+	Packages []string
+}
+
+func (c *installPackagesCommand) Shell() string {
+	return "apt-get install -y " + strings.Join(c.Packages, " ")
+}
+
+func (c *installPackagesCommand) Reverse() string {
+	return "apt-get remove -y " + strings.Join(c.Packages, " ")
+}
+
+// AddUser returns a command that creates the named user (optionally as a
+// system user), and knows how to undo that via userdel.
+func AddUser(name string, system bool) *addUserCommand {
+	return &addUserCommand{Name: name, System: system}
+}
+
+type addUserCommand struct {
+	Name   string
+	System bool
+}
+
+func (c *addUserCommand) Shell() string {
+	if c.System {
+		return fmt.Sprintf("useradd --system %s", c.Name)
+	}
+	return fmt.Sprintf("useradd %s", c.Name)
+}
+
+func (c *addUserCommand) Reverse() string {
+	return fmt.Sprintf("userdel %s", c.Name)
+}
+
+// Mkdir returns a command that creates path owned by owner with the given
+// mode, and knows how to undo that via rmdir.
+func Mkdir(path, owner string, mode os.FileMode) *mkdirCommand {
+	return &mkdirCommand{Path: path, Owner: owner, Mode: mode}
+}
+
+type mkdirCommand struct {
+	Path  string
+	Owner string
+	Mode  os.FileMode
+}
+
+func (c *mkdirCommand) Shell() string {
+	return fmt.Sprintf("mkdir -p -m %o %s && chown %s %s", c.Mode, c.Path, c.Owner, c.Path)
+}
+
+func (c *mkdirCommand) Reverse() string {
+	return fmt.Sprintf("rmdir %s", c.Path)
+}
+
+// WriteFile returns a command that writes content to path on the target,
+// owned by owner with the given mode.
+func WriteFile(path, content, owner string, mode os.FileMode) *writeFileCommand {
+	return &writeFileCommand{Path: path, Content: content, Owner: owner, Mode: mode}
+}
+
+type writeFileCommand struct {
+	Path    string
+	Content string
+	Owner   string
+	Mode    os.FileMode
+}
+
+func (c *writeFileCommand) Shell() string {
+	return fmt.Sprintf("cat > %s <<EOF\n%s\nEOF\nchown %s %s && chmod %o %s", c.Path, c.Content, c.Owner, c.Path, c.Mode, c.Path)
+}
+
+// Inputs folds Content into the command's checksum (see cmd.Inputs). Content
+// is also part of Shell(), so a change to a template body such as
+// ElasticSearch's config.yml invalidates the cache entry either way.
+func (c *writeFileCommand) Inputs() [][]byte {
+	return [][]byte{[]byte(c.Content)}
+}
@@ -0,0 +1,87 @@
+package urknall
+
+import "fmt"
+
+// Version is the urknall release that produced a build plan. It has no
+// bearing on behavior and exists purely so consumers of Plan's output can
+// tell which semantics generated it.
+var Version = "dev"
+
+// BuildPlan is the machine-readable description of what a Build would do,
+// produced by Plan. It mirrors the events DryRun publishes on the pubsub bus,
+// but as a single document rather than a stream, so CI systems can diff
+// plans across runs, gate deploys on "no changes", or feed the plan into a
+// policy engine.
+type BuildPlan struct {
+	Hostname       string      `json:"hostname"`
+	User           string      `json:"user"`
+	Template       string      `json:"template"`
+	UrknallVersion string      `json:"urknall_version"`
+	Offline        bool        `json:"offline"`
+	Tasks          []*TaskPlan `json:"tasks"`
+}
+
+// TaskPlan is the planned execution of a single task.
+type TaskPlan struct {
+	Name     string         `json:"name"`
+	Commands []*CommandPlan `json:"commands"`
+}
+
+// CommandPlan is the planned execution of a single command within a task.
+type CommandPlan struct {
+	Message  string   `json:"message"`
+	Shell    string   `json:"shell"`
+	Checksum string   `json:"checksum"`
+	Cached   bool     `json:"cached"`
+	Env      []string `json:"env"`
+}
+
+// Plan renders the build's template and returns a BuildPlan describing every
+// task and command that would be executed, without actually running any of
+// them. The result can be handed to json.Marshal directly.
+//
+// If offline is true the target's cache directory is never consulted:
+// prepareTarget and buildChecksumTree are skipped entirely, and every
+// command's checksum is reported as "unknown" rather than computed. This
+// allows a plan to be produced for targets that are not currently reachable,
+// e.g. when there is no SSH access to the host being planned for.
+func (b *Build) Plan(offline bool) (*BuildPlan, error) {
+	pkg, e := renderTemplate(b.Template)
+	if e != nil {
+		return nil, e
+	}
+
+	if !offline {
+		if e = b.prepareTargetAndTasks(pkg); e != nil {
+			return nil, e
+		}
+	}
+
+	plan := &BuildPlan{
+		Hostname:       b.hostname(),
+		User:           b.User(),
+		Template:       fmt.Sprintf("%T", b.Template),
+		UrknallVersion: Version,
+		Offline:        offline,
+	}
+
+	for _, task := range pkg.tasks {
+		tp := &TaskPlan{Name: task.name}
+		for _, command := range task.commands {
+			checksum := "unknown"
+			if !offline {
+				checksum = command.Checksum()
+			}
+			tp.Commands = append(tp.Commands, &CommandPlan{
+				Message:  command.LogMsg(),
+				Shell:    command.command.Shell(),
+				Checksum: checksum,
+				Cached:   command.cached,
+				Env:      b.Env,
+			})
+		}
+		plan.Tasks = append(plan.Tasks, tp)
+	}
+
+	return plan, nil
+}
@@ -0,0 +1,59 @@
+package urknall
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestBuildPlanJSON only exercises BuildPlan's JSON shape, not Plan itself --
+// calling Plan requires a Build.Target and Build.Template, and neither
+// Target nor Template is defined anywhere in this tree (they live in files
+// outside this change), so there is no way to construct one here.
+func TestBuildPlanJSON(t *testing.T) {
+	Convey("Given a build plan for an offline run", t, func() {
+		plan := &BuildPlan{
+			Hostname:       "host-a",
+			User:           "root",
+			Template:       "*main.ElasticSearch",
+			UrknallVersion: Version,
+			Offline:        true,
+			Tasks: []*TaskPlan{
+				{
+					Name: "base",
+					Commands: []*CommandPlan{
+						{Message: "install openjdk", Shell: "apt-get install -y openjdk-6-jdk", Checksum: "unknown", Cached: false},
+					},
+				},
+			},
+		}
+
+		b, e := json.Marshal(plan)
+		So(e, ShouldBeNil)
+
+		var decoded map[string]interface{}
+		So(json.Unmarshal(b, &decoded), ShouldBeNil)
+
+		Convey("Top-level metadata round-trips", func() {
+			So(decoded["hostname"], ShouldEqual, "host-a")
+			So(decoded["user"], ShouldEqual, "root")
+			So(decoded["offline"], ShouldEqual, true)
+		})
+
+		Convey("Tasks and their commands round-trip", func() {
+			tasks := decoded["tasks"].([]interface{})
+			So(len(tasks), ShouldEqual, 1)
+
+			task := tasks[0].(map[string]interface{})
+			So(task["name"], ShouldEqual, "base")
+
+			commands := task["commands"].([]interface{})
+			So(len(commands), ShouldEqual, 1)
+
+			command := commands[0].(map[string]interface{})
+			So(command["checksum"], ShouldEqual, "unknown")
+			So(command["cached"], ShouldEqual, false)
+		})
+	})
+}
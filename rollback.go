@@ -0,0 +1,154 @@
+package urknall
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/dynport/urknall/cmd"
+	"github.com/dynport/urknall/pubsub"
+)
+
+// journalEntry records that a command ran successfully as part of a task, so
+// Rollback can later undo it. Reverse is empty when the command doesn't
+// implement cmd.Reversible, in which case Rollback skips it with a warning
+// instead of aborting.
+type journalEntry struct {
+	Shell   string `json:"shell"`
+	Reverse string `json:"reverse,omitempty"`
+}
+
+// journalPath is where a task's rollback journal is stored, alongside its
+// `.done`/`.run` checksum files under the cache directory.
+func (build *Build) journalPath(taskName string) string {
+	return fmt.Sprintf(ukCACHEDIR+"/%s/journal.jsonl", taskName)
+}
+
+// truncateJournal clears taskName's rollback journal. It must be called
+// once, before a task's commands are (re-)run: without it, a command whose
+// definition changes and re-executes would leave its stale entry from the
+// previous run in the journal alongside the new one, and Rollback would
+// replay both -- including the stale one, against a host that no longer
+// matches it.
+func (build *Build) truncateJournal(taskName string) error {
+	journalPath := build.journalPath(taskName)
+	rawCmd := fmt.Sprintf("mkdir -p -m2775 %s && : > %s", filepath.Dir(journalPath), journalPath)
+	internal, e := build.prepareInternalCommand(rawCmd)
+	if e != nil {
+		return e
+	}
+	return internal.Run()
+}
+
+// appendJournal records that c ran successfully as part of taskName.
+func (build *Build) appendJournal(taskName string, c cmd.Command) error {
+	entry := journalEntry{Shell: c.Shell()}
+	if r, ok := c.(cmd.Reversible); ok {
+		entry.Reverse = r.Reverse()
+	}
+
+	line, e := json.Marshal(entry)
+	if e != nil {
+		return e
+	}
+
+	rawCmd := fmt.Sprintf("echo %s >> %s", shellQuote(string(line)), build.journalPath(taskName))
+	internal, e := build.prepareInternalCommand(rawCmd)
+	if e != nil {
+		return e
+	}
+	return internal.Run()
+}
+
+// parseJournal parses the newline-delimited JSON raw read from a task's
+// journal file into the journalEntry values it contains, in the order they
+// were written -- i.e. the order the commands ran in, since truncateJournal
+// clears the file at the start of each run. Blank lines are ignored.
+func parseJournal(raw string, taskName string) ([]journalEntry, error) {
+	var entries []journalEntry
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		var entry journalEntry
+		if e := json.Unmarshal([]byte(line), &entry); e != nil {
+			return nil, fmt.Errorf("invalid rollback journal entry %q for task %q: %s", line, taskName, e.Error())
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Rollback undoes taskName's effect by replaying its journal in reverse,
+// running Reverse() for each journaled command that implemented
+// cmd.Reversible. Commands that didn't are skipped with a warning, since
+// there is nothing recorded that would undo them.
+func (build *Build) Rollback(taskName string) error {
+	out := &bytes.Buffer{}
+	journalPath := build.journalPath(taskName)
+	catCmd, e := build.prepareInternalCommand(fmt.Sprintf("[ -f %s ] && cat %s || true", journalPath, journalPath))
+	if e != nil {
+		return e
+	}
+	catCmd.SetStdout(out)
+	if e := catCmd.Run(); e != nil {
+		return fmt.Errorf("failed to read rollback journal for task %q: %s", taskName, e.Error())
+	}
+
+	entries, e := parseJournal(out.String(), taskName)
+	if e != nil {
+		return e
+	}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+
+		m := message(pubsub.MessageRunlistsProvisionTask, build.hostname(), taskName)
+		if entry.Reverse == "" {
+			m.Message = "skipping irreversible command: " + entry.Shell
+			m.Publish("skipped")
+			continue
+		}
+
+		revCmd, e := build.prepareCommand(entry.Reverse)
+		if e != nil {
+			return e
+		}
+		if e := revCmd.Run(); e != nil {
+			return fmt.Errorf("rollback of task %q failed undoing %q: %s", taskName, entry.Shell, e.Error())
+		}
+	}
+
+	return nil
+}
+
+// RunWithRollback behaves like Run, except that if a command within a task
+// fails, the commands that already succeeded within that same task are
+// rolled back immediately, leaving the host as it was before the task
+// started instead of half-applied.
+func (build *Build) RunWithRollback() error {
+	pkg, e := build.prepareBuild()
+	if e != nil {
+		return e
+	}
+
+	for _, task := range pkg.tasks {
+		if e := build.buildTask(task); e != nil {
+			if rbErr := build.Rollback(task.name); rbErr != nil {
+				return fmt.Errorf("task %q failed (%s) and rollback also failed: %s", task.name, e.Error(), rbErr.Error())
+			}
+			return e
+		}
+	}
+
+	return nil
+}
+
+// shellQuote wraps s in single quotes for safe inclusion in a shell command,
+// escaping any single quotes it contains.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
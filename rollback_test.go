@@ -0,0 +1,91 @@
+package urknall
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestParseJournal below covers how Rollback reads a task's journal: entries
+// come back in the order they were written, which Rollback then replays in
+// reverse. That reverse replay, truncateJournal, and Rollback/RunWithRollback
+// end-to-end are not covered: they need a real Target, which isn't defined
+// anywhere in this tree, so that's as far as this can go.
+func TestShellQuote(t *testing.T) {
+	Convey("Given strings to quote for shell inclusion", t, func() {
+		Convey("A plain string is wrapped in single quotes", func() {
+			So(shellQuote("hello"), ShouldEqual, "'hello'")
+		})
+
+		Convey("A string containing single quotes is escaped", func() {
+			So(shellQuote("it's here"), ShouldEqual, `'it'\''s here'`)
+		})
+	})
+}
+
+func TestParseJournal(t *testing.T) {
+	Convey("Given a journal with blank lines between entries", t, func() {
+		raw := `{"shell":"useradd a","reverse":"userdel a"}
+
+{"shell":"mkdir /b"}
+{"shell":"useradd c","reverse":"userdel c"}
+`
+		entries, e := parseJournal(raw, "base")
+		So(e, ShouldBeNil)
+
+		Convey("Blank lines are skipped and entries keep the order they were written in", func() {
+			So(len(entries), ShouldEqual, 3)
+			So(entries[0].Shell, ShouldEqual, "useradd a")
+			So(entries[1].Reverse, ShouldEqual, "")
+			So(entries[2].Shell, ShouldEqual, "useradd c")
+		})
+	})
+
+	Convey("Given a journal that only reflects the current run, since truncateJournal clears it beforehand", t, func() {
+		// Without truncation, a task run that journals A, then re-runs a
+		// changed B a second time, would leave a stale first-run B entry
+		// ahead of the fresh one; Rollback would replay both, and the
+		// second (stale) Reverse() call would run against a host state it
+		// no longer matches. truncateJournal's whole point is that the raw
+		// text Rollback ever sees only contains the entries from the run
+		// that actually produced the task's current state.
+		raw := `{"shell":"useradd a","reverse":"userdel a"}
+{"shell":"mkdir /b (v2)","reverse":"rmdir /b"}
+`
+		entries, e := parseJournal(raw, "base")
+		So(e, ShouldBeNil)
+		So(len(entries), ShouldEqual, 2)
+		So(entries[1].Shell, ShouldEqual, "mkdir /b (v2)")
+	})
+
+	Convey("Given a journal entry that isn't valid JSON", t, func() {
+		_, e := parseJournal("not json", "base")
+		So(e, ShouldNotBeNil)
+	})
+}
+
+func TestJournalEntryRoundTrip(t *testing.T) {
+	Convey("Given a journal entry for a reversible command", t, func() {
+		entry := journalEntry{Shell: "useradd foo", Reverse: "userdel foo"}
+
+		line, e := json.Marshal(entry)
+		So(e, ShouldBeNil)
+
+		var decoded journalEntry
+		So(json.Unmarshal(line, &decoded), ShouldBeNil)
+		So(decoded, ShouldResemble, entry)
+	})
+
+	Convey("Given a journal entry for an irreversible command", t, func() {
+		entry := journalEntry{Shell: "echo hi"}
+
+		line, e := json.Marshal(entry)
+		So(e, ShouldBeNil)
+		So(string(line), ShouldNotContainSubstring, "reverse")
+
+		var decoded journalEntry
+		So(json.Unmarshal(line, &decoded), ShouldBeNil)
+		So(decoded.Reverse, ShouldEqual, "")
+	})
+}
@@ -0,0 +1,139 @@
+package urknall
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// RunManyOptions configures a RunMany call.
+type RunManyOptions struct {
+	// MaxConcurrency bounds how many targets are provisioned at the same
+	// time. Zero (the default) means all targets are started at once.
+	MaxConcurrency int
+
+	// FailFast aborts targets that have not started yet as soon as any
+	// target fails. Targets already running are left to finish or fail on
+	// their own; they are not interrupted mid-task.
+	FailFast bool
+
+	// PerTargetTemplate, if set, is called once per target to get the
+	// Template actually rendered and provisioned for that target, instead of
+	// the tpl passed to RunMany. Use this to vary per-target Template
+	// fields -- e.g. a different DiscoveryHosts for each ElasticSearch node
+	// -- since those are baked in at render time and PerTargetEnv cannot
+	// reach them: Build.Env only affects the shell environment commands run
+	// in, not template rendering.
+	PerTargetTemplate func(Target) Template
+
+	// PerTargetEnv, if set, is called once per target to build that
+	// target's Build.Env, so callers can inject host-specific configuration
+	// commands themselves read out of the environment.
+	PerTargetEnv func(Target) []string
+}
+
+// RunMany provisions tpl against every target in targets concurrently,
+// bounded by opts.MaxConcurrency. Unless opts.PerTargetTemplate is set, tpl
+// is rendered and validated exactly once; each target then gets its own
+// clonePackage copy of the result to prepare and run, since a task's
+// commands carry per-target mutable state (whether they're cached on that
+// particular host) that would race if shared across goroutines. When
+// opts.PerTargetTemplate is set, each target's own Template is rendered
+// instead, since the whole point is for their field values to differ. Each
+// target runs against its own *Build, so its pubsub message stream is
+// naturally tagged with its own hostname and failures on one target don't
+// affect another's run.
+//
+// Errors from individual targets are collected rather than aborting the
+// whole group; the returned error, if any, describes every target that
+// failed. Set opts.FailFast to stop starting new targets once the first
+// failure is observed.
+func RunMany(targets []Target, tpl Template, opts RunManyOptions) error {
+	var sharedPkg *packageImpl
+	if opts.PerTargetTemplate == nil {
+		pkg, e := renderTemplate(tpl)
+		if e != nil {
+			return e
+		}
+		sharedPkg = pkg
+	}
+
+	max := opts.MaxConcurrency
+	if max <= 0 {
+		max = len(targets)
+	}
+	sem := make(chan struct{}, max)
+
+	var aborted int32
+	errs := make([]error, len(targets))
+	hostnames := make([]string, len(targets))
+
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(i int, target Target) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			targetTpl := tpl
+			b := &Build{Target: target, Template: targetTpl}
+			hostnames[i] = b.hostname()
+
+			if opts.FailFast && atomic.LoadInt32(&aborted) == 1 {
+				errs[i] = fmt.Errorf("skipped after an earlier target failed")
+				return
+			}
+
+			var pkg *packageImpl
+			if opts.PerTargetTemplate != nil {
+				targetTpl = opts.PerTargetTemplate(target)
+				b.Template = targetTpl
+				rendered, e := renderTemplate(targetTpl)
+				if e != nil {
+					errs[i] = e
+					return
+				}
+				pkg = rendered
+			} else {
+				pkg = clonePackage(sharedPkg)
+			}
+
+			if opts.PerTargetEnv != nil {
+				b.Env = opts.PerTargetEnv(target)
+			}
+
+			if e := b.prepareTargetAndTasks(pkg); e != nil {
+				errs[i] = e
+			} else if e := b.runTasks(pkg); e != nil {
+				errs[i] = e
+			}
+
+			if errs[i] != nil && opts.FailFast {
+				atomic.StoreInt32(&aborted, 1)
+			}
+		}(i, target)
+	}
+	wg.Wait()
+
+	return aggregateRunManyErrors(hostnames, errs)
+}
+
+// aggregateRunManyErrors collects the per-target errors from RunMany into a
+// single error naming every failed target's hostname, or nil if none
+// failed.
+func aggregateRunManyErrors(hostnames []string, errs []error) error {
+	var msgs []string
+	for i, e := range errs {
+		if e == nil {
+			continue
+		}
+		msgs = append(msgs, fmt.Sprintf("%s: %s", hostnames[i], e.Error()))
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d/%d targets failed:\n%s", len(msgs), len(hostnames), strings.Join(msgs, "\n"))
+}
@@ -0,0 +1,32 @@
+package urknall
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestAggregateRunManyErrors only covers the error-collection helper, not
+// RunMany's actual concurrency, per-target isolation or FailFast behavior --
+// those require a real Target, and Target is not defined anywhere in this
+// tree (it lives in a file outside this change), so there's no way to build
+// one here to drive RunMany with.
+func TestAggregateRunManyErrors(t *testing.T) {
+	Convey("Given a RunMany result with no failures", t, func() {
+		e := aggregateRunManyErrors([]string{"a", "b"}, []error{nil, nil})
+		So(e, ShouldBeNil)
+	})
+
+	Convey("Given a RunMany result with some failures", t, func() {
+		e := aggregateRunManyErrors(
+			[]string{"host-a", "host-b", "host-c"},
+			[]error{nil, errors.New("boom"), errors.New("kaboom")},
+		)
+		So(e, ShouldNotBeNil)
+		So(e.Error(), ShouldContainSubstring, "2/3 targets failed")
+		So(e.Error(), ShouldContainSubstring, "host-b: boom")
+		So(e.Error(), ShouldContainSubstring, "host-c: kaboom")
+		So(e.Error(), ShouldNotContainSubstring, "host-a")
+	})
+}
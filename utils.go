@@ -1,9 +1,6 @@
 package urknall
 
 import (
-	"crypto/sha256"
-	"fmt"
-
 	"github.com/dynport/urknall/cmd"
 )
 
@@ -17,6 +14,23 @@ func renderTemplate(builder Template) (*packageImpl, error) {
 	return p, nil
 }
 
+// clonePackage returns a fresh *packageImpl with the same tasks and commands
+// as pkg, but its own independent per-command cache state. RunMany renders a
+// shared template once and then gives each target one of these clones to
+// prepare and run concurrently: prepareTask mutates a command's cached flag,
+// so handing every target the same *packageImpl would race.
+func clonePackage(pkg *packageImpl) *packageImpl {
+	clone := &packageImpl{reference: pkg.reference}
+	for _, tsk := range pkg.tasks {
+		commands := make([]cmd.Command, len(tsk.commands))
+		for i, c := range tsk.commands {
+			commands[i] = c.command
+		}
+		clone.AddCommands(tsk.name, commands...)
+	}
+	return clone
+}
+
 func executeCommand(cmd cmd.Command, build *Build, checksumDir, taskName string) (e error) {
 	sCmd := cmd.Shell()
 	for _, env := range build.Env {
@@ -26,15 +40,28 @@ func executeCommand(cmd cmd.Command, build *Build, checksumDir, taskName string)
 	return r.run()
 }
 
-func commandChecksum(c cmd.Command) (string, error) {
+// commandChecksum computes the checksum stored for c's cache entry, prefixed
+// with alg's name so caches mixing algorithms on the same host stay valid.
+// Commands implementing their own Checksum() keep full control over their
+// digest, but it is still stored under customChecksumPrefix rather than raw,
+// so buildChecksumTree's "<algorithm>-<hex>" parsing -- which runs over every
+// cache entry regardless of which kind of command produced it -- doesn't
+// reject it for lacking a prefix. Commands implementing Inputs() have that
+// data appended to the Shell() text before hashing, so a change to any of
+// it invalidates the cache entry even where Shell() itself didn't change.
+func commandChecksum(alg ChecksumAlgorithm, c cmd.Command) (string, error) {
 	if c, ok := c.(interface {
 		Checksum() string
 	}); ok {
-		return c.Checksum(), nil
+		return customChecksumPrefix + "-" + c.Checksum(), nil
 	}
-	s := sha256.New()
-	if _, e := s.Write([]byte(c.Shell())); e != nil {
-		return "", e
+
+	payload := []byte(c.Shell())
+	if ic, ok := c.(cmd.Inputs); ok {
+		for _, in := range ic.Inputs() {
+			payload = append(payload, in...)
+		}
 	}
-	return fmt.Sprintf("%x", s.Sum(nil)), nil
+
+	return alg.Name() + "-" + alg.Sum(payload), nil
 }